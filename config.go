@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest describes several bbf/bbfBatch jobs to run in one invocation,
+// via --config. Fields left unset on a job fall back to Defaults, which in
+// turn fall back to whatever flags were passed on the command line.
+type Manifest struct {
+	Defaults ManifestParams `yaml:"defaults" json:"defaults"`
+	Jobs     []ManifestJob  `yaml:"jobs" json:"jobs"`
+}
+
+type ManifestJob struct {
+	Input          string `yaml:"input" json:"input"`
+	Output         string `yaml:"output" json:"output"`
+	ManifestParams `yaml:",inline"`
+}
+
+// ManifestParams mirrors Params/BatchOptions with every field optional, so
+// a manifest can override exactly the settings it cares about and leave
+// the rest to Defaults or the command-line flags.
+type ManifestParams struct {
+	Blur   *float64 `yaml:"blur,omitempty" json:"blur,omitempty"`
+	Width  *int     `yaml:"width,omitempty" json:"width,omitempty"`
+	Height *int     `yaml:"height,omitempty" json:"height,omitempty"`
+	Radius *int     `yaml:"radius,omitempty" json:"radius,omitempty"`
+	Margin *int     `yaml:"margin,omitempty" json:"margin,omitempty"`
+
+	SkipExisting *bool `yaml:"skip_existing,omitempty" json:"skip_existing,omitempty"`
+	Overwrite    *bool `yaml:"overwrite,omitempty" json:"overwrite,omitempty"`
+	DoneMarker   *bool `yaml:"done_marker,omitempty" json:"done_marker,omitempty"`
+
+	Watermark         *string  `yaml:"watermark,omitempty" json:"watermark,omitempty"`
+	WatermarkPosition *string  `yaml:"watermark_position,omitempty" json:"watermark_position,omitempty"`
+	WatermarkOpacity  *float64 `yaml:"watermark_opacity,omitempty" json:"watermark_opacity,omitempty"`
+	WatermarkScale    *float64 `yaml:"watermark_scale,omitempty" json:"watermark_scale,omitempty"`
+
+	OverlayShape     *string  `yaml:"overlay_shape,omitempty" json:"overlay_shape,omitempty"`
+	SquircleExponent *float64 `yaml:"squircle_exponent,omitempty" json:"squircle_exponent,omitempty"`
+	ShadowRadius     *float64 `yaml:"shadow_radius,omitempty" json:"shadow_radius,omitempty"`
+	ShadowOffsetX    *int     `yaml:"shadow_offset_x,omitempty" json:"shadow_offset_x,omitempty"`
+	ShadowOffsetY    *int     `yaml:"shadow_offset_y,omitempty" json:"shadow_offset_y,omitempty"`
+	ShadowOpacity    *float64 `yaml:"shadow_opacity,omitempty" json:"shadow_opacity,omitempty"`
+
+	Jobs           *int    `yaml:"jobs,omitempty" json:"jobs,omitempty"`
+	Extensions     *string `yaml:"extensions,omitempty" json:"extensions,omitempty"`
+	Recursive      *bool   `yaml:"recursive,omitempty" json:"recursive,omitempty"`
+	FollowSymlinks *bool   `yaml:"follow_symlinks,omitempty" json:"follow_symlinks,omitempty"`
+}
+
+func loadManifest(path string) (Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	var manifest Manifest
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &manifest)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &manifest)
+	default:
+		return Manifest{}, fmt.Errorf("unsupported config extension (want .json, .yaml, or .yml): %s", path)
+	}
+	return manifest, err
+}
+
+// applyManifestParams overlays every non-nil field of mp onto params/opts,
+// leaving fields mp doesn't mention untouched.
+func applyManifestParams(params Params, opts BatchOptions, mp ManifestParams) (Params, BatchOptions) {
+	if mp.Blur != nil {
+		params.blur = *mp.Blur
+	}
+	if mp.Width != nil {
+		params.width = *mp.Width
+	}
+	if mp.Height != nil {
+		params.height = *mp.Height
+	}
+	if mp.Radius != nil {
+		params.radius = *mp.Radius
+	}
+	if mp.Margin != nil {
+		params.margin = *mp.Margin
+	}
+	if mp.SkipExisting != nil {
+		params.skipExisting = *mp.SkipExisting
+	}
+	if mp.Overwrite != nil {
+		params.overwrite = *mp.Overwrite
+	}
+	if mp.DoneMarker != nil {
+		params.doneMarker = *mp.DoneMarker
+	}
+	if mp.Watermark != nil {
+		params.watermark = *mp.Watermark
+	}
+	if mp.WatermarkPosition != nil {
+		params.watermarkPosition = *mp.WatermarkPosition
+	}
+	if mp.WatermarkOpacity != nil {
+		params.watermarkOpacity = *mp.WatermarkOpacity
+	}
+	if mp.WatermarkScale != nil {
+		params.watermarkScale = *mp.WatermarkScale
+	}
+	if mp.OverlayShape != nil {
+		params.overlayShape = *mp.OverlayShape
+	}
+	if mp.SquircleExponent != nil {
+		params.squircleExponent = *mp.SquircleExponent
+	}
+	if mp.ShadowRadius != nil {
+		params.shadowRadius = *mp.ShadowRadius
+	}
+	if mp.ShadowOffsetX != nil {
+		params.shadowOffsetX = *mp.ShadowOffsetX
+	}
+	if mp.ShadowOffsetY != nil {
+		params.shadowOffsetY = *mp.ShadowOffsetY
+	}
+	if mp.ShadowOpacity != nil {
+		params.shadowOpacity = *mp.ShadowOpacity
+	}
+	if mp.Jobs != nil {
+		opts.jobs = *mp.Jobs
+	}
+	if mp.Extensions != nil {
+		opts.extensions = parseExtensions(*mp.Extensions)
+	}
+	if mp.Recursive != nil {
+		opts.recursive = *mp.Recursive
+	}
+	if mp.FollowSymlinks != nil {
+		opts.followSymlinks = *mp.FollowSymlinks
+	}
+	return params, opts
+}
+
+// runManifest expands every job in the manifest at configPath, running bbf
+// or bbfBatch depending on whether the job's input is a file or directory,
+// and returns non-zero if any job failed.
+func runManifest(configPath string, baseParams Params, baseOpts BatchOptions) int {
+	manifest, err := loadManifest(configPath)
+	if err != nil {
+		fmt.Println("Failed to load config:", configPath, err)
+		return 1
+	}
+
+	rc := 0
+	for i, job := range manifest.Jobs {
+		params, opts := applyManifestParams(baseParams, baseOpts, manifest.Defaults)
+		params, opts = applyManifestParams(params, opts, job.ManifestParams)
+		params.input = job.Input
+		params.output = job.Output
+
+		if job.Input == "" {
+			fmt.Println("Job", i, "has no input")
+			rc = 1
+			continue
+		}
+
+		fileInfo, err := os.Stat(job.Input)
+		if err != nil {
+			fmt.Println("Job", i, "input not found:", job.Input, err)
+			rc = 1
+			continue
+		}
+
+		if fileInfo.IsDir() {
+			if bbfBatch(job.Input, job.Output, params, opts) != 0 {
+				rc = 1
+			}
+		} else if bbf(params) != 0 {
+			rc = 1
+		}
+	}
+
+	return rc
+}
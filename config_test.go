@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestApplyManifestParamsOverridesOnlySetFields(t *testing.T) {
+	base := Params{blur: 20, width: 1920, height: 1080}
+	baseOpts := BatchOptions{jobs: 4, recursive: true}
+
+	blur := 5.0
+	recursive := false
+	mp := ManifestParams{Blur: &blur, Recursive: &recursive}
+
+	params, opts := applyManifestParams(base, baseOpts, mp)
+
+	if params.blur != blur {
+		t.Errorf("expected blur override to apply, got %v", params.blur)
+	}
+	if params.width != base.width {
+		t.Errorf("expected width to be left untouched, got %v", params.width)
+	}
+	if opts.recursive != recursive {
+		t.Errorf("expected recursive override to apply, got %v", opts.recursive)
+	}
+	if opts.jobs != baseOpts.jobs {
+		t.Errorf("expected jobs to be left untouched, got %v", opts.jobs)
+	}
+}
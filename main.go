@@ -3,25 +3,44 @@ package main
 import (
 	"flag"
 	"fmt"
+	"hash/fnv"
 	"image"
-	"image/color"
 	"io/fs"
-	"math"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/disintegration/imaging"
 )
 
 type Params struct {
-	input  string
-	output string
-	blur   float64
-	height int
-	width  int
-	radius int
-	margin int
+	input        string
+	output       string
+	blur         float64
+	height       int
+	width        int
+	radius       int
+	margin       int
+	skipExisting bool
+	overwrite    bool
+	doneMarker   bool
+
+	watermark         string
+	watermarkPosition string
+	watermarkOpacity  float64
+	watermarkScale    float64
+
+	overlayShape     string
+	squircleExponent float64
+	shadowRadius     float64
+	shadowOffsetX    int
+	shadowOffsetY    int
+	shadowOpacity    float64
 }
 
 func bbf(params Params) int {
@@ -51,12 +70,26 @@ func bbf(params Params) int {
 	maxHeight := params.height - 2*params.margin
 	overlay := imaging.Fit(src, maxWidth, maxHeight, imaging.Lanczos)
 
-	overlay = applyRoundedCorners(overlay, params.radius)
+	masker := newMasker(params)
+	overlay = masker.Mask(overlay)
 
 	x := (params.width - overlay.Bounds().Dx()) / 2
 	y := (params.height - overlay.Bounds().Dy()) / 2
+
+	if params.shadowRadius > 0 {
+		bg = applyShadow(bg, overlay, x, y, params)
+	}
+
 	result := imaging.Overlay(bg, overlay, image.Pt(x, y), 1.0)
 
+	if params.watermark != "" {
+		result, err = applyWatermark(result, params)
+		if err != nil {
+			fmt.Println("Failed to apply watermark:", err)
+			return 1
+		}
+	}
+
 	err = imaging.Save(result, params.output)
 	if err != nil {
 		fmt.Println("Failed to save output:", params.output, err)
@@ -66,80 +99,231 @@ func bbf(params Params) int {
 	return 0
 }
 
-func applyRoundedCorners(img image.Image, radius int) *image.NRGBA {
-	w := img.Bounds().Dx()
-	h := img.Bounds().Dy()
-	dst := imaging.New(w, h, color.Transparent)
+// applyWatermark resizes the configured watermark image to a fraction of
+// the output width, positions it per params.watermarkPosition, and blends
+// it onto base at params.watermarkOpacity.
+func applyWatermark(base *image.NRGBA, params Params) (*image.NRGBA, error) {
+	wm, err := imaging.Open(params.watermark)
+	if err != nil {
+		return nil, err
+	}
 
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			alpha := roundedRectAlpha(x, y, w, h, radius)
-			if alpha == 0.0 {
-				continue
-			}
+	targetWidth := int(float64(params.width) * params.watermarkScale)
+	wm = imaging.Resize(wm, targetWidth, 0, imaging.Lanczos)
 
-			r, g, b, _ := img.At(x, y).RGBA()
-			dst.SetNRGBA(x, y, color.NRGBA{
-				R: uint8(r >> 8),
-				G: uint8(g >> 8),
-				B: uint8(b >> 8),
-				A: uint8(alpha * 255),
-			})
+	x, y := watermarkOffset(params.watermarkPosition, base.Bounds().Dx(), base.Bounds().Dy(), wm.Bounds().Dx(), wm.Bounds().Dy())
+
+	return imaging.Overlay(base, wm, image.Pt(x, y), params.watermarkOpacity), nil
+}
+
+func watermarkOffset(position string, bgWidth, bgHeight, wmWidth, wmHeight int) (int, int) {
+	const margin = 20
+	switch position {
+	case "tl":
+		return margin, margin
+	case "tr":
+		return bgWidth - wmWidth - margin, margin
+	case "bl":
+		return margin, bgHeight - wmHeight - margin
+	case "br":
+		return bgWidth - wmWidth - margin, bgHeight - wmHeight - margin
+	default: // "center"
+		return (bgWidth - wmWidth) / 2, (bgHeight - wmHeight) / 2
+	}
+}
+
+type batchResult struct {
+	input string
+	err   error
+}
+
+// BatchOptions controls how bbfBatch enumerates and schedules work, as
+// opposed to Params which controls how each individual image is rendered.
+type BatchOptions struct {
+	jobs           int
+	extensions     map[string]bool
+	recursive      bool
+	followSymlinks bool
+}
+
+const defaultExtensions = "jpg,jpeg,png,tiff,webp,bmp"
+
+// parseExtensions turns a comma-separated, case-insensitive extension list
+// (with or without leading dots) into a lookup set keyed the same way
+// strings.ToLower(filepath.Ext(path)) produces.
+func parseExtensions(raw string) map[string]bool {
+	set := map[string]bool{}
+	for _, ext := range strings.Split(raw, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
 		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		set[ext] = true
 	}
-	return dst
+	return set
 }
 
-func roundedRectAlpha(x, y, w, h, r int) float64 {
-	samples := 4
-	sampleSize := 1.0 / float64(samples)
-	hit := 0
-	total := samples * samples
+// walkFollowingSymlinks walks dir like filepath.WalkDir, but additionally
+// descends into symlinked subdirectories. visited tracks the device/inode
+// of every directory entered so far, so a symlink cycle is skipped instead
+// of recursing forever.
+func walkFollowingSymlinks(dir string, visited map[string]bool, emit func(string)) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Println("Error while reading directory:", dir, err)
+		return
+	}
 
-	for sy := 0; sy < samples; sy++ {
-		for sx := 0; sx < samples; sx++ {
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
 
-			px := float64(x) + (float64(sx)+0.5)*sampleSize
-			py := float64(y) + (float64(sy)+0.5)*sampleSize
+		if entry.Type()&fs.ModeSymlink != 0 {
+			resolved, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				fmt.Println("Failed to resolve symlink:", path, err)
+				continue
+			}
+			info, err := os.Stat(resolved)
+			if err != nil {
+				fmt.Println("Failed to stat symlink target:", path, err)
+				continue
+			}
+			if !info.IsDir() {
+				emit(path)
+				continue
+			}
 
-			if insideRoundedRect(px, py, w, h, r) {
-				hit++
+			key := inodeKey(resolved, info)
+			if visited[key] {
+				fmt.Println("Skipping symlink cycle:", path)
+				continue
 			}
+			visited[key] = true
+			// Recurse via the symlink path, not resolved, so emitted files
+			// keep the logical layout rooted at the original input dir
+			// instead of jumping to wherever the symlink target lives.
+			walkFollowingSymlinks(path, visited, emit)
+			continue
 		}
+
+		if entry.IsDir() {
+			info, err := entry.Info()
+			if err != nil {
+				fmt.Println("Error while reading directory entry:", path, err)
+				continue
+			}
+			key := inodeKey(path, info)
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+			walkFollowingSymlinks(path, visited, emit)
+			continue
+		}
+
+		emit(path)
+	}
+}
+
+// inodeKey identifies a directory by device+inode where supported, falling
+// back to its (ideally canonical) path so cycle detection degrades
+// gracefully on platforms without syscall.Stat_t.
+func inodeKey(path string, info os.FileInfo) string {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)
 	}
-	return float64(hit) / float64(total)
+	return path
+}
+
+type doneMarker struct {
+	size  int64
+	mtime time.Time
+	hash  string
 }
 
-func insideRoundedRect(px, py float64, w, h, r int) bool {
-	ir := float64(r)
-	left := ir
-	right := float64(w) - ir
-	top := ir
-	bottom := float64(h) - ir
+func doneMarkerPath(outputFile string) string {
+	return outputFile + ".done"
+}
+
+// paramsHash fingerprints every Params field that affects bbf's pixel
+// output (i.e. everything except input/output paths and the batch-only
+// skip/overwrite/done-marker toggles), so a done marker written under one
+// set of flags is detected as stale when any of those flags change.
+func paramsHash(params Params) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%g|%d|%d|%d|%d|%s|%s|%g|%g|%s|%g|%g|%d|%d|%g",
+		params.blur, params.width, params.height, params.radius, params.margin,
+		params.watermark, params.watermarkPosition, params.watermarkOpacity, params.watermarkScale,
+		params.overlayShape, params.squircleExponent,
+		params.shadowRadius, params.shadowOffsetX, params.shadowOffsetY, params.shadowOpacity)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
 
-	switch {
-	case px < left && py < top:
-		return dist(px, py, left, top) <= ir
-	case px > right && py < top:
-		return dist(px, py, right, top) <= ir
-	case px < left && py > bottom:
-		return dist(px, py, left, bottom) <= ir
-	case px > right && py > bottom:
-		return dist(px, py, right, bottom) <= ir
-	default:
+func writeDoneMarker(outputFile string, inputFile string, params Params) error {
+	inInfo, err := os.Stat(inputFile)
+	if err != nil {
+		return err
+	}
+	content := fmt.Sprintf("%d\n%d\n%s\n", inInfo.Size(), inInfo.ModTime().UnixNano(), paramsHash(params))
+	return os.WriteFile(doneMarkerPath(outputFile), []byte(content), 0644)
+}
 
-		return px >= 0 && px <= float64(w) && py >= 0 && py <= float64(h)
+func readDoneMarker(path string) (doneMarker, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return doneMarker{}, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		return doneMarker{}, fmt.Errorf("malformed done marker: %s", path)
+	}
+	size, err := strconv.ParseInt(lines[0], 10, 64)
+	if err != nil {
+		return doneMarker{}, err
 	}
+	mtimeNano, err := strconv.ParseInt(lines[1], 10, 64)
+	if err != nil {
+		return doneMarker{}, err
+	}
+	return doneMarker{size: size, mtime: time.Unix(0, mtimeNano), hash: lines[2]}, nil
 }
 
-func dist(x1, y1, x2, y2 float64) float64 {
-	dx := x1 - x2
-	dy := y1 - y2
-	return math.Sqrt(dx*dx + dy*dy)
+// shouldSkipFile reports whether inputFile can be skipped because
+// outputFile already reflects it under the current params. Without a done
+// marker this is a plain mtime comparison; with one it also catches an
+// input that was replaced in place with an older mtime, and forces
+// reprocessing when params have changed since the marker was written.
+func shouldSkipFile(inputFile string, outputFile string, params Params) (bool, error) {
+	inInfo, err := os.Stat(inputFile)
+	if err != nil {
+		return false, err
+	}
+	outInfo, err := os.Stat(outputFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if !params.doneMarker {
+		return outInfo.ModTime().After(inInfo.ModTime()), nil
+	}
+
+	marker, err := readDoneMarker(doneMarkerPath(outputFile))
+	if err != nil {
+		return false, nil
+	}
+	if marker.size != inInfo.Size() || !marker.mtime.Equal(inInfo.ModTime()) {
+		return false, nil
+	}
+	return marker.hash == paramsHash(params), nil
 }
 
-func bbfBatch(inputDir string, outputDir string, params Params) int {
-	rc := 0
+func bbfBatch(inputDir string, outputDir string, params Params, opts BatchOptions) int {
 	absInputDir, err := filepath.Abs(inputDir)
 	if err != nil {
 		fmt.Println("Failed to find absolute input path:", inputDir, err)
@@ -156,10 +340,10 @@ func bbfBatch(inputDir string, outputDir string, params Params) int {
 	}
 	err = os.MkdirAll(absOutputDir, 0755)
 	if err != nil {
-		outputDir, err = filepath.Abs(filepath.Join(inputDir, "../out"))
-		fmt.Println("Failed to create output path, relocating to:", outputDir, err)
+		absOutputDir, err = filepath.Abs(filepath.Join(inputDir, "../out"))
+		fmt.Println("Failed to create output path, relocating to:", absOutputDir, err)
 		if err != nil {
-			fmt.Println("Failed to find absolute fallback output path:", outputDir, err)
+			fmt.Println("Failed to find absolute fallback output path:", absOutputDir, err)
 			return 1
 		}
 		err = os.MkdirAll(absOutputDir, 0755)
@@ -168,23 +352,116 @@ func bbfBatch(inputDir string, outputDir string, params Params) int {
 			return 1
 		}
 	}
-	filepath.WalkDir(absInputDir, func(inputFile string, d fs.DirEntry, err error) error {
-		if d.IsDir() {
-			return nil
+
+	jobs := opts.jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+
+	start := time.Now()
+	inputs := make(chan string, jobs*2)
+	results := make(chan batchResult, jobs*2)
+
+	var workers sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for inputFile := range inputs {
+				rel, relErr := filepath.Rel(absInputDir, inputFile)
+				if relErr != nil {
+					results <- batchResult{input: inputFile, err: fmt.Errorf("failed to derive output path for %s: %w", inputFile, relErr)}
+					continue
+				}
+
+				jobParams := params
+				jobParams.input = inputFile
+				jobParams.output = filepath.Join(absOutputDir, rel)
+
+				if params.skipExisting && !params.overwrite {
+					skip, skipErr := shouldSkipFile(jobParams.input, jobParams.output, params)
+					if skipErr == nil && skip {
+						fmt.Println("Skipped (up to date):", inputFile)
+						results <- batchResult{input: inputFile}
+						continue
+					}
+				}
+
+				var jobErr error
+				if bbf(jobParams) != 0 {
+					jobErr = fmt.Errorf("failed to process %s", inputFile)
+				} else if params.doneMarker {
+					if markErr := writeDoneMarker(jobParams.output, jobParams.input, params); markErr != nil {
+						fmt.Println("Failed to write done marker for", jobParams.output, markErr)
+					}
+				}
+				results <- batchResult{input: inputFile, err: jobErr}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(inputs)
+
+		emit := func(path string) {
+			if !opts.extensions[strings.ToLower(filepath.Ext(path))] {
+				fmt.Println("Ignored (unsupported extension):", path)
+				return
+			}
+			inputs <- path
 		}
-		if err != nil {
-			rc = 2
-			fmt.Println("Error while walking the directory tree:", err)
-			return nil
+
+		if opts.recursive && opts.followSymlinks {
+			visited := map[string]bool{}
+			if rootInfo, err := os.Stat(absInputDir); err == nil {
+				visited[inodeKey(absInputDir, rootInfo)] = true
+			}
+			walkFollowingSymlinks(absInputDir, visited, emit)
+		} else if opts.recursive {
+			filepath.WalkDir(absInputDir, func(inputFile string, d fs.DirEntry, err error) error {
+				if err != nil {
+					fmt.Println("Error while walking the directory tree:", err)
+					return nil
+				}
+				if d.IsDir() {
+					return nil
+				}
+				emit(inputFile)
+				return nil
+			})
+		} else {
+			entries, err := os.ReadDir(absInputDir)
+			if err != nil {
+				fmt.Println("Error while reading the directory:", err)
+				return
+			}
+			for _, entry := range entries {
+				if entry.IsDir() {
+					continue
+				}
+				emit(filepath.Join(absInputDir, entry.Name()))
+			}
 		}
-		outputFile := strings.Replace(inputFile, inputDir, outputDir, 1)
-		params.input = inputFile
-		params.output = outputFile
-		if bbf(params) != 0 {
+	}()
+
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	rc := 0
+	processed := 0
+	failed := 0
+	for res := range results {
+		processed++
+		if res.err != nil {
+			failed++
 			rc = 2
+			fmt.Println(res.err)
 		}
-		return nil
-	})
+	}
+
+	fmt.Printf("Processed %d files, %d failed, in %s\n", processed, failed, time.Since(start))
 
 	return rc
 }
@@ -194,11 +471,29 @@ func main() {
 	output := flag.String("output", "", "Path to output image")
 	inputDir := flag.String("input-dir", "", "Path to input dir")
 	outputDir := flag.String("output-dir", "", "Path to output dir")
+	config := flag.String("config", "", "Path to a .yaml/.yml/.json job manifest; cannot be combined with --input/--input-dir")
 	blur := flag.Float64("blur", 20.0, "Blur radius for background")
 	width := flag.Int("width", 1920, "Output image width")
 	height := flag.Int("height", 1080, "Output image height")
 	radius := flag.Int("radius", 20, "Overlay corner radius")
 	margin := flag.Int("margin", 20, "Overlay margin")
+	jobs := flag.Int("jobs", runtime.NumCPU(), "Number of worker goroutines for --input-dir batches")
+	skipExisting := flag.Bool("skip-existing", false, "Skip inputs whose output is already up to date (--input-dir only)")
+	overwrite := flag.Bool("overwrite", false, "Force reprocessing even when --skip-existing would skip a file")
+	doneMarker := flag.Bool("done-marker", false, "Write a .done marker recording input size/mtime/params hash, and use it to detect param changes with --skip-existing")
+	extensions := flag.String("extensions", defaultExtensions, "Comma-separated, case-insensitive list of file extensions to process in --input-dir batches")
+	recursive := flag.Bool("recursive", true, "Recurse into subdirectories of --input-dir")
+	followSymlinks := flag.Bool("follow-symlinks", false, "Follow symlinked subdirectories when walking --input-dir (cycle-safe)")
+	watermark := flag.String("watermark", "", "Path to a watermark/logo image to composite onto the output")
+	watermarkPosition := flag.String("watermark-position", "br", "Watermark position: tl, tr, bl, br, or center")
+	watermarkOpacity := flag.Float64("watermark-opacity", 1.0, "Watermark opacity, 0.0-1.0")
+	watermarkScale := flag.Float64("watermark-scale", 0.2, "Watermark width as a fraction of the output width")
+	overlayShape := flag.String("overlay-shape", "rect", "Overlay clip shape: rect, circle, or squircle")
+	squircleExponent := flag.Float64("squircle-exponent", 4.0, "Superellipse exponent for --overlay-shape=squircle")
+	shadowRadius := flag.Float64("shadow-radius", 0.0, "Drop shadow blur radius behind the overlay; 0 disables the shadow")
+	shadowOffsetX := flag.Int("shadow-offset-x", 0, "Drop shadow horizontal offset in pixels")
+	shadowOffsetY := flag.Int("shadow-offset-y", 10, "Drop shadow vertical offset in pixels")
+	shadowOpacity := flag.Float64("shadow-opacity", 0.5, "Drop shadow opacity, 0.0-1.0")
 
 	flag.Parse()
 
@@ -210,16 +505,45 @@ func main() {
 		height: *height,
 		radius: *radius,
 		margin: *margin,
+
+		skipExisting: *skipExisting,
+		overwrite:    *overwrite,
+		doneMarker:   *doneMarker,
+
+		watermark:         *watermark,
+		watermarkPosition: *watermarkPosition,
+		watermarkOpacity:  *watermarkOpacity,
+		watermarkScale:    *watermarkScale,
+
+		overlayShape:     *overlayShape,
+		squircleExponent: *squircleExponent,
+		shadowRadius:     *shadowRadius,
+		shadowOffsetX:    *shadowOffsetX,
+		shadowOffsetY:    *shadowOffsetY,
+		shadowOpacity:    *shadowOpacity,
 	}
 
+	opts := BatchOptions{
+		jobs:           *jobs,
+		extensions:     parseExtensions(*extensions),
+		recursive:      *recursive,
+		followSymlinks: *followSymlinks,
+	}
+
+	configUsed := *config != ""
 	inputUsed := params.input != ""
 	inputDirUsed := *inputDir != ""
 
-	if inputUsed && inputDirUsed {
+	if configUsed && (inputUsed || inputDirUsed) {
+		fmt.Println("You may only use --config OR --input/--input-dir")
+		os.Exit(1)
+	} else if configUsed {
+		os.Exit(runManifest(*config, params, opts))
+	} else if inputUsed && inputDirUsed {
 		fmt.Println("You may only use --input OR --input-dir")
 		os.Exit(1)
 	} else if !inputUsed && !inputDirUsed {
-		fmt.Println("You must use --input OR --input-dir")
+		fmt.Println("You must use --config, --input, OR --input-dir")
 		os.Exit(1)
 	} else if inputUsed {
 		fileInfo, err := os.Stat(params.input)
@@ -234,7 +558,7 @@ func main() {
 			fmt.Println("Not a directory / does not exist:", *inputDir)
 			os.Exit(1)
 		}
-		os.Exit(bbfBatch(*inputDir, *outputDir, params))
+		os.Exit(bbfBatch(*inputDir, *outputDir, params, opts))
 	} else {
 		fmt.Println("You've somehow broken the fundamental axioms of logic itself, congratulations!")
 		os.Exit(1)
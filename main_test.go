@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWalkFollowingSymlinksCycle(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.png"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(root, filepath.Join(sub, "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	visited := map[string]bool{}
+	if rootInfo, err := os.Stat(root); err == nil {
+		visited[inodeKey(root, rootInfo)] = true
+	}
+
+	var seen []string
+	walkFollowingSymlinks(root, visited, func(path string) {
+		seen = append(seen, path)
+	})
+
+	count := 0
+	for _, p := range seen {
+		if filepath.Base(p) == "a.png" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("expected a.png to be emitted exactly once, got %d (seen: %v)", count, seen)
+	}
+}
+
+func TestParamsHashDetectsChanges(t *testing.T) {
+	base := Params{blur: 20, width: 1920, height: 1080, radius: 20, margin: 20}
+	baseline := paramsHash(base)
+
+	variants := []Params{
+		{blur: 21, width: 1920, height: 1080, radius: 20, margin: 20},
+		{blur: 20, width: 1920, height: 1080, radius: 20, margin: 20, watermark: "logo.png"},
+		{blur: 20, width: 1920, height: 1080, radius: 20, margin: 20, overlayShape: "circle"},
+		{blur: 20, width: 1920, height: 1080, radius: 20, margin: 20, shadowRadius: 8},
+	}
+
+	for i, v := range variants {
+		if paramsHash(v) == baseline {
+			t.Errorf("variant %d: expected paramsHash to change from baseline, got same hash %s", i, baseline)
+		}
+	}
+
+	if repeat := paramsHash(base); repeat != baseline {
+		t.Errorf("expected paramsHash to be stable for identical params, got %s vs %s", repeat, baseline)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func alphaAt(img *image.NRGBA, x, y int) uint8 {
+	_, _, _, a := img.At(x, y).RGBA()
+	return uint8(a >> 8)
+}
+
+func TestMaskerShapesKeepCenterOpaque(t *testing.T) {
+	const size = 40
+	src := imaging.New(size, size, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+	maskers := map[string]Masker{
+		"rect":     rectMasker{radius: 0},
+		"circle":   circleMasker{},
+		"squircle": squircleMasker{exponent: 4},
+	}
+
+	for name, masker := range maskers {
+		out := masker.Mask(src)
+		if alphaAt(out, size/2, size/2) == 0 {
+			t.Errorf("%s: expected center pixel to be opaque", name)
+		}
+	}
+}
+
+func TestCircleMaskerClipsCorners(t *testing.T) {
+	const size = 40
+	src := imaging.New(size, size, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+	out := circleMasker{}.Mask(src)
+	if a := alphaAt(out, 0, 0); a != 0 {
+		t.Errorf("expected corner pixel to be fully transparent, got alpha %d", a)
+	}
+}
+
+func TestRectMaskerWithZeroRadiusKeepsCorners(t *testing.T) {
+	const size = 40
+	src := imaging.New(size, size, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+	out := rectMasker{radius: 0}.Mask(src)
+	if a := alphaAt(out, 0, 0); a == 0 {
+		t.Errorf("expected corner pixel to remain opaque with radius 0, got alpha %d", a)
+	}
+}